@@ -0,0 +1,115 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+// FormatQuirks reports per-field anomalies observed while detecting a
+// block's format. Real-world archives often mix conventions from more than
+// one tar variant (e.g., USTAR magic with GNU base-256 binary numeric
+// fields, or a PAX header preceding a GNU-style LongLink), which a single
+// best-guess Format cannot express on its own.
+type FormatQuirks struct {
+	BinaryNumerics  bool // A numeric field uses GNU base-256 encoding rather than octal.
+	NonASCIIName    bool // Name contains a byte with the high bit set.
+	PrefixUsed      bool // The USTAR/STAR prefix field is non-empty.
+	UnknownTypeflag bool // Typeflag isn't recognized by any format in the best guess.
+	STARTrailer     bool // The STAR "tar\x00" trailer is present alongside GNU magic.
+	SpaceTerminated bool // An octal numeric field is terminated by a space instead of a NUL.
+}
+
+// DetectFormat performs a more liberal scan of b than GetFormat. Rather
+// than trusting magic/version/trailer alone, it additionally inspects the
+// numeric fields for base-256 (binary) vs. octal encoding and for space vs.
+// NUL termination, checks the name for non-ASCII bytes, checks whether the
+// prefix field is used, validates the typeflag against the best-guess
+// format's known set, and checks for a STAR trailer coexisting with GNU
+// magic. It returns both the best-guess
+// Format (identical to what GetFormat would return) and a FormatQuirks
+// describing anomalies the best guess glosses over. Like GetFormat, it
+// returns FormatUnknown only when the checksum itself fails.
+//
+// Downstream writers can use the quirks to decide whether to normalize a
+// block to a clean single format or to preserve it as-is via the raw-block
+// capture API.
+func (b *Block) DetectFormat() (Format, FormatQuirks) {
+	var q FormatQuirks
+
+	guess := b.GetFormat()
+	if guess == FormatUnknown {
+		return guess, q
+	}
+
+	numericFields := [][]byte{
+		b.V7().Mode(), b.V7().UID(), b.V7().GID(), b.V7().Size(), b.V7().ModTime(),
+	}
+	if guess.has(FormatGNU) {
+		numericFields = append(numericFields,
+			b.GNU().DevMajor(), b.GNU().DevMinor(),
+			b.GNU().AccessTime(), b.GNU().ChangeTime(), b.GNU().RealSize())
+	}
+	for _, field := range numericFields {
+		if len(field) == 0 {
+			continue
+		}
+		if field[0]&0x80 != 0 {
+			q.BinaryNumerics = true
+			continue
+		}
+		// POSIX terminates octal fields with a NUL; older writers (e.g.
+		// V7, some Sun tars) instead use a trailing space.
+		if last := field[len(field)-1]; last == ' ' {
+			q.SpaceTerminated = true
+		}
+	}
+
+	for _, c := range b.V7().Name() {
+		if c >= 0x80 {
+			q.NonASCIIName = true
+			break
+		}
+	}
+
+	if guess.has(FormatUSTAR | FormatPAX | FormatSTAR) {
+		prefix := b.USTAR().Prefix()
+		if guess.has(FormatSTAR) {
+			prefix = b.STAR().Prefix()
+		}
+		for _, c := range prefix {
+			if c != 0 {
+				q.PrefixUsed = true
+				break
+			}
+		}
+	}
+
+	if !validTypeflag(guess, b.V7().TypeFlag()[0]) {
+		q.UnknownTypeflag = true
+	}
+
+	magic := string(b.USTAR().Magic())
+	if magic == magicGNU && string(b.STAR().Trailer()) == trailerSTAR {
+		q.STARTrailer = true
+	}
+
+	return guess, q
+}
+
+// validTypeflag reports whether typeFlag is among the values used by any
+// of the formats in guess.
+func validTypeflag(guess Format, typeFlag byte) bool {
+	switch typeFlag {
+	case 0, '0', '1', '2':
+		return true // Regular file, hard link, symlink: valid everywhere, including V7.
+	case '3', '4', '5', '6':
+		return guess.has(FormatUSTAR | FormatPAX | FormatGNU | FormatSTAR)
+	case '7':
+		return guess.has(FormatUSTAR | FormatPAX | FormatSTAR)
+	case TypeXHeader, TypeXGlobalHeader:
+		return guess.has(FormatUSTAR | FormatPAX)
+	case TypeGNULongName, TypeGNULongLink, TypeGNUSparse:
+		return guess.has(FormatGNU)
+	default:
+		return false
+	}
+}
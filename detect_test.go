@@ -0,0 +1,60 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import "testing"
+
+func newValidBlock(t *testing.T) Block {
+	t.Helper()
+	var b Block
+	b.SetFormat(FormatUSTAR | FormatPAX)
+	copy(b.V7().Name(), "foo.txt")
+	b.V7().TypeFlag()[0] = '0'
+	b.SetFormat(FormatUSTAR | FormatPAX) // Recompute checksum.
+	return b
+}
+
+func TestDetectFormatSpaceTerminated(t *testing.T) {
+	b := newValidBlock(t)
+	mode := b.V7().Mode()
+	mode[len(mode)-1] = ' '              // Old-style space terminator instead of NUL.
+	b.SetFormat(FormatUSTAR | FormatPAX) // Recompute checksum over the new field.
+
+	_, q := b.DetectFormat()
+	if !q.SpaceTerminated {
+		t.Errorf("SpaceTerminated = false, want true")
+	}
+}
+
+func TestDetectFormatNoSpuriousQuirks(t *testing.T) {
+	b := newValidBlock(t)
+	_, q := b.DetectFormat()
+	if q != (FormatQuirks{}) {
+		t.Errorf("quirks = %+v, want none", q)
+	}
+}
+
+func TestDetectFormatBinaryNumerics(t *testing.T) {
+	b := newValidBlock(t)
+	var f formatter
+	f.formatNumeric(b.V7().Size(), 1<<34) // Too large for octal Size; forces base-256.
+	b.SetFormat(FormatUSTAR | FormatPAX)  // Recompute checksum.
+
+	_, q := b.DetectFormat()
+	if !q.BinaryNumerics {
+		t.Errorf("BinaryNumerics = false, want true")
+	}
+}
+
+func TestDetectFormatChecksumFailure(t *testing.T) {
+	var b Block // All zero: fails the checksum check.
+	got, q := b.DetectFormat()
+	if got != FormatUnknown {
+		t.Errorf("Format = %v, want FormatUnknown", got)
+	}
+	if q != (FormatQuirks{}) {
+		t.Errorf("quirks = %+v, want none", q)
+	}
+}
@@ -42,7 +42,8 @@ import "strings"
 // such as supported string encodings, support for sub-second timestamps,
 // or support for sparse files.
 //
-// The Writer currently provides no support for sparse files.
+// Sparse files can be written in either the GNU or PAX formats; see
+// SparseEntries and the GNUSparseHeader/GNUSparsePAXRecords helpers.
 type Format int
 
 // Constants to identify various tar formats.
@@ -53,8 +54,15 @@ const (
 	// FormatUnknown indicates that the format is unknown.
 	FormatUnknown
 
-	// The format of the original Unix V7 tar tool prior to standardization.
-	formatV7
+	// FormatV7 represents the format of the original Unix V7 tar tool
+	// prior to standardization.
+	//
+	// This is the most limited of the formats: it has no magic value, no
+	// uname/gname, no prefix, no device numbers, and only supports
+	// regular files, hard links, and symlinks. Uid/gid are limited to
+	// uint21, size to uint33 (8GiB), and names to 100 bytes with no
+	// prefix. See FitsV7.
+	FormatV7
 
 	// FormatUSTAR represents the USTAR header format defined in POSIX.1-1988.
 	//
@@ -97,10 +105,17 @@ const (
 	//	https://www.gnu.org/software/tar/manual/html_node/Standard.html
 	FormatGNU
 
-	// Schily's tar format, which is incompatible with USTAR.
-	// This does not cover STAR extensions to the PAX format; these fall under
-	// the PAX format.
-	formatSTAR
+	// FormatSTAR represents Jörg Schilling's star tar format, which is
+	// incompatible with USTAR. This does not cover STAR extensions to the
+	// PAX format; those fall under the PAX format.
+	//
+	// STAR extends USTAR with a Prefix field as well as AccessTime and
+	// ChangeTime, and is identified by a "tar\x00" trailer following the
+	// USTAR magic/version.
+	//
+	// Reference:
+	//	http://www.mkssoftware.com/docs/man4/tar.4.asp
+	FormatSTAR
 
 	formatMax
 )
@@ -111,7 +126,7 @@ func (f *Format) mayOnlyBe(f2 Format) { *f &= f2 }
 func (f *Format) mustNotBe(f2 Format) { *f &^= f2 }
 
 var formatNames = map[Format]string{
-	formatV7: "V7", FormatUSTAR: "USTAR", FormatPAX: "PAX", FormatGNU: "GNU", formatSTAR: "STAR",
+	FormatV7: "V7", FormatUSTAR: "USTAR", FormatPAX: "PAX", FormatGNU: "GNU", FormatSTAR: "STAR",
 }
 
 func (f Format) String() string {
@@ -145,6 +160,38 @@ const (
 	prefixSize = 155 // Max length of the prefix field in USTAR format
 )
 
+// Typeflag values used to identify the type of a tar entry.
+// Only the values needed to distinguish special (non-regular-file) entries
+// are listed here; ordinary file types ('0', '1', '2', etc.) have no named
+// constants and are written as character literals where checked (see
+// FitsV7).
+const (
+	// TypeGNUSparse identifies a GNU old-style sparse file entry. The
+	// block's GNU sparse map (and any chained extension blocks) describes
+	// the fragments of actual data; see GNUSparseHeader.
+	TypeGNUSparse = 'S'
+
+	// TypeGNULongName identifies a GNU long-name entry: a header whose
+	// data payload holds the name of the next entry in the archive,
+	// overriding its (truncated) Name field.
+	TypeGNULongName = 'L'
+
+	// TypeGNULongLink identifies a GNU long-link entry: a header whose
+	// data payload holds the link target of the next entry in the
+	// archive, overriding its (truncated) LinkName field.
+	TypeGNULongLink = 'K'
+
+	// TypeXHeader identifies a PAX extended header: a header whose data
+	// payload holds key=value records (see formatPAXRecord) that override
+	// or extend fields of the next entry in the archive.
+	TypeXHeader = 'x'
+
+	// TypeXGlobalHeader identifies a PAX global extended header, whose
+	// records apply as defaults to every subsequent entry in the archive
+	// rather than to a single next entry.
+	TypeXGlobalHeader = 'g'
+)
+
 // blockPadding computes the number of bytes needed to pad offset up to the
 // nearest block edge where 0 <= n < blockSize.
 func blockPadding(offset int64) (n int64) {
@@ -180,13 +227,13 @@ func (b *Block) GetFormat() Format {
 	trailer := string(b.STAR().Trailer())
 	switch {
 	case magic == magicUSTAR && trailer == trailerSTAR:
-		return formatSTAR
+		return FormatSTAR
 	case magic == magicUSTAR:
 		return FormatUSTAR | FormatPAX
 	case magic == magicGNU && version == versionGNU:
 		return FormatGNU
 	default:
-		return formatV7
+		return FormatV7
 	}
 }
 
@@ -195,12 +242,17 @@ func (b *Block) GetFormat() Format {
 func (b *Block) SetFormat(format Format) {
 	// Set the magic values.
 	switch {
-	case format.has(formatV7):
-		// Do nothing.
+	case format.has(FormatV7):
+		// V7 has no magic value; zero the bytes every other format uses
+		// for magic/version/uname/gname/etc. so stale data left over from
+		// a previous use of this Block isn't mistaken for one.
+		for i := 257; i < blockSize; i++ {
+			b[i] = 0
+		}
 	case format.has(FormatGNU):
 		copy(b.GNU().Magic(), magicGNU)
 		copy(b.GNU().Version(), versionGNU)
-	case format.has(formatSTAR):
+	case format.has(FormatSTAR):
 		copy(b.STAR().Magic(), magicUSTAR)
 		copy(b.STAR().Version(), versionUSTAR)
 		copy(b.STAR().Trailer(), trailerSTAR)
@@ -240,6 +292,27 @@ func (b *Block) Reset() {
 	*b = Block{}
 }
 
+// Limits of the fields representable by the Unix V7 format.
+const (
+	v7MaxUID  = 1<<21 - 1 // uint21
+	v7MaxGID  = 1<<21 - 1 // uint21
+	v7MaxSize = 1 << 33   // uint33, i.e. 8GiB
+)
+
+// FitsV7 reports whether a header with the given field values can be
+// represented losslessly in the Unix V7 format: uid and gid must fit in
+// 21 bits, size must be less than 8GiB, name must be at most 100 bytes
+// with no prefix, and typeflag must be one of the few V7 recognizes.
+func FitsV7(uid, gid, size int64, name, prefix string, typeflag byte) bool {
+	switch typeflag {
+	case 0, '0', '1', '2':
+	default:
+		return false
+	}
+	return uid <= v7MaxUID && gid <= v7MaxGID && size < v7MaxSize &&
+		len(name) <= nameSize && prefix == ""
+}
+
 type HeaderV7 [blockSize]byte
 
 func (h *HeaderV7) Name() []byte     { return h[000:][:100] }
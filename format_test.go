@@ -0,0 +1,64 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import "testing"
+
+func TestFitsV7(t *testing.T) {
+	tests := []struct {
+		name           string
+		uid, gid, size int64
+		fname, prefix  string
+		typeflag       byte
+		want           bool
+	}{
+		{"uid at limit", v7MaxUID, 0, 0, "a", "", '0', true},
+		{"uid over limit", v7MaxUID + 1, 0, 0, "a", "", '0', false},
+		{"gid at limit", 0, v7MaxGID, 0, "a", "", '0', true},
+		{"gid over limit", 0, v7MaxGID + 1, 0, "a", "", '0', false},
+		{"size at limit", 0, 0, v7MaxSize - 1, "a", "", '0', true},
+		{"size over limit", 0, 0, v7MaxSize, "a", "", '0', false},
+		{"name at limit", 0, 0, 0, string(make([]byte, nameSize)), "", '0', true},
+		{"name over limit", 0, 0, 0, string(make([]byte, nameSize+1)), "", '0', false},
+		{"prefix present", 0, 0, 0, "a", "p", '0', false},
+		{"typeflag zero byte ok", 0, 0, 0, "a", "", 0, true},
+		{"typeflag reg ok", 0, 0, 0, "a", "", '0', true},
+		{"typeflag link ok", 0, 0, 0, "a", "", '1', true},
+		{"typeflag symlink ok", 0, 0, 0, "a", "", '2', true},
+		{"typeflag dir rejected", 0, 0, 0, "a", "", '5', false},
+		{"typeflag gnu sparse rejected", 0, 0, 0, "a", "", TypeGNUSparse, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FitsV7(tt.uid, tt.gid, tt.size, tt.fname, tt.prefix, tt.typeflag)
+			if got != tt.want {
+				t.Errorf("FitsV7(%d, %d, %d, %q, %q, %q) = %v, want %v",
+					tt.uid, tt.gid, tt.size, tt.fname, tt.prefix, tt.typeflag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFormatGetFormatRoundTrip(t *testing.T) {
+	tests := []Format{FormatV7, FormatGNU, FormatSTAR, FormatUSTAR | FormatPAX}
+	for _, format := range tests {
+		var b Block
+		b.SetFormat(format)
+		if got := b.GetFormat(); !got.has(format) {
+			t.Errorf("SetFormat(%v) then GetFormat() = %v, want a format including %v", format, got, format)
+		}
+	}
+}
+
+func TestSetFormatV7ZeroesExtension(t *testing.T) {
+	var b Block
+	copy(b.GNU().Magic(), magicGNU) // Simulate stale GNU data left in the block.
+	b.SetFormat(FormatV7)
+	for i := 257; i < blockSize; i++ {
+		if b[i] != 0 {
+			t.Fatalf("byte %d = %#x, want 0 after SetFormat(FormatV7)", i, b[i])
+		}
+	}
+}
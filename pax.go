@@ -0,0 +1,152 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paxSchilyXattr is the PAX record key prefix used by libarchive and
+// Jörg Schilling's star to carry POSIX extended attributes, and honored on
+// read by GNU tar.
+const paxSchilyXattr = "SCHILY.xattr."
+
+// PAXRecord is a single decoded key/value pair from a PAX extended or
+// global header.
+type PAXRecord struct {
+	Key   string
+	Value string
+}
+
+// XattrRecords returns one PAX record per entry in xattrs, keyed
+// "SCHILY.xattr.<name>", for inclusion in a PAX extended header's payload
+// (see PAXHeaderPayload). Records are emitted in sorted-by-name order so
+// that encoding the same xattrs twice produces byte-identical output.
+func XattrRecords(xattrs map[string][]byte) []string {
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recs := make([]string, 0, len(xattrs))
+	for _, name := range names {
+		recs = append(recs, formatPAXRecord(paxSchilyXattr+name, string(xattrs[name])))
+	}
+	return recs
+}
+
+// GlobalRecords returns one PAX record per entry in fields, for inclusion
+// in a PAX global extended header's payload (Typeflag TypeXGlobalHeader;
+// see PAXHeaderPayload). Records are emitted in sorted-by-key order so that
+// encoding the same fields twice produces byte-identical output.
+func GlobalRecords(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	recs := make([]string, 0, len(fields))
+	for _, k := range keys {
+		recs = append(recs, formatPAXRecord(k, fields[k]))
+	}
+	return recs
+}
+
+// PAXHeaderPayload concatenates recs into the data payload of a PAX
+// extended or global header entry (the blocks that follow a TypeXHeader or
+// TypeXGlobalHeader header block), NUL-padded to a 512-byte boundary.
+func PAXHeaderPayload(recs []string) []byte {
+	var buf []byte
+	for _, r := range recs {
+		buf = append(buf, r...)
+	}
+	if n := blockPadding(int64(len(buf))); n > 0 {
+		buf = append(buf, make([]byte, n)...)
+	}
+	return buf
+}
+
+// PAXExtendedHeader writes a PAX extended or global header into b: it sets
+// Typeflag to TypeXGlobalHeader if global, or TypeXHeader otherwise, and
+// sets Size to the unpadded length of recs (e.g. as returned by
+// XattrRecords or GlobalRecords), so Size reflects only the record data
+// and not the NUL padding that follows it. It returns the entry's data
+// payload, NUL-padded to a 512-byte boundary, which the caller must write
+// to the archive immediately following b.
+func PAXExtendedHeader(b *Block, global bool, recs []string) []byte {
+	if global {
+		b.V7().TypeFlag()[0] = TypeXGlobalHeader
+	} else {
+		b.V7().TypeFlag()[0] = TypeXHeader
+	}
+	var n int
+	for _, r := range recs {
+		n += len(r)
+	}
+	var f formatter
+	f.formatNumeric(b.V7().Size(), int64(n))
+	return PAXHeaderPayload(recs)
+}
+
+// ParsePAXRecords decodes the payload of a PAX extended or global header,
+// as produced by PAXHeaderPayload, into its key/value records in order.
+// Trailing NUL padding is ignored.
+func ParsePAXRecords(payload []byte) ([]PAXRecord, error) {
+	var records []PAXRecord
+	s := string(payload)
+	for len(s) > 0 && s[0] != 0 {
+		var rec PAXRecord
+		var err error
+		rec.Key, rec.Value, s, err = parsePAXRecord(s)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// SplitXattrs partitions records into extended attributes, keyed
+// "SCHILY.xattr.<name>" with the prefix stripped, and all other standard
+// PAX keys (e.g. "path", "mtime"), so callers can distinguish the two.
+func SplitXattrs(records []PAXRecord) (xattrs map[string][]byte, other map[string]string) {
+	xattrs = make(map[string][]byte)
+	other = make(map[string]string)
+	for _, r := range records {
+		if strings.HasPrefix(r.Key, paxSchilyXattr) {
+			xattrs[strings.TrimPrefix(r.Key, paxSchilyXattr)] = []byte(r.Value)
+			continue
+		}
+		other[r.Key] = r.Value
+	}
+	return xattrs, other
+}
+
+// parsePAXRecord splits a single "LENGTH KEY=VALUE\n" record (as produced
+// by formatPAXRecord) off the front of s, returning its key, value, and
+// the remainder of s following the record.
+func parsePAXRecord(s string) (key, value, remainder string, err error) {
+	sp := strings.IndexByte(s, ' ')
+	if sp < 0 {
+		return "", "", s, fmt.Errorf("rawtar: invalid PAX record: %q", s)
+	}
+	n, perr := strconv.ParseInt(s[:sp], 10, 64)
+	if perr != nil || n < int64(len("5 a=\n")) || n > int64(len(s)) {
+		return "", "", s, fmt.Errorf("rawtar: invalid PAX record: %q", s)
+	}
+	rec, remainder := s[:n], s[n:]
+
+	rec = rec[sp+1 : len(rec)-1] // Strip "LENGTH " prefix and "\n" suffix
+	eq := strings.IndexByte(rec, '=')
+	if eq < 0 {
+		return "", "", s, fmt.Errorf("rawtar: invalid PAX record: %q", s)
+	}
+	return rec[:eq], rec[eq+1:], remainder, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatPAXRecordFixedPoint(t *testing.T) {
+	// Exercise keys/values whose lengths push the record length across a
+	// digit-count boundary (99 -> 100, 999 -> 1000), which is exactly where a
+	// naive one-shot computation of the self-referential length goes wrong.
+	tests := []struct{ key, value string }{
+		{"a", "b"},
+		{"path", "foo.txt"},
+		{"k", string(make([]byte, 94))},  // Record length is 101, not the naive 100.
+		{"k", string(make([]byte, 993))}, // Record length is 1001, not the naive 1000.
+	}
+	for _, tt := range tests {
+		rec := formatPAXRecord(tt.key, tt.value)
+		gotKey, gotValue, rem, err := parsePAXRecord(rec)
+		if err != nil {
+			t.Errorf("key=%q len(value)=%d: parsePAXRecord: %v", tt.key, len(tt.value), err)
+			continue
+		}
+		if rem != "" {
+			t.Errorf("key=%q: leftover remainder %q", tt.key, rem)
+		}
+		if gotKey != tt.key || gotValue != tt.value {
+			t.Errorf("key=%q len(value)=%d: got (%q, %q), want (%q, %q)",
+				tt.key, len(tt.value), gotKey, gotValue, tt.key, tt.value)
+		}
+	}
+}
+
+func TestPAXHeaderPayloadRoundTrip(t *testing.T) {
+	recs := []string{
+		formatPAXRecord("path", "foo.txt"),
+		formatPAXRecord("mtime", "1234567890.5"),
+	}
+	payload := PAXHeaderPayload(recs)
+	if len(payload)%blockSize != 0 {
+		t.Fatalf("len(payload) = %d, not a multiple of %d", len(payload), blockSize)
+	}
+
+	got, err := ParsePAXRecords(payload)
+	if err != nil {
+		t.Fatalf("ParsePAXRecords: %v", err)
+	}
+	want := []PAXRecord{{"path", "foo.txt"}, {"mtime", "1234567890.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %+v, want %+v", got, want)
+	}
+}
+
+func TestXattrRecordsDeterministic(t *testing.T) {
+	xattrs := map[string][]byte{
+		"user.z": []byte("3"),
+		"user.a": []byte("1"),
+		"user.m": []byte("2"),
+	}
+	first := XattrRecords(xattrs)
+	for i := 0; i < 10; i++ {
+		if got := XattrRecords(xattrs); !reflect.DeepEqual(got, first) {
+			t.Fatalf("XattrRecords is non-deterministic: got %v, want %v", got, first)
+		}
+	}
+
+	payload := PAXHeaderPayload(first)
+	records, err := ParsePAXRecords(payload)
+	if err != nil {
+		t.Fatalf("ParsePAXRecords: %v", err)
+	}
+	gotXattrs, other := SplitXattrs(records)
+	if len(other) != 0 {
+		t.Errorf("other = %v, want empty", other)
+	}
+	want := map[string][]byte{"user.z": []byte("3"), "user.a": []byte("1"), "user.m": []byte("2")}
+	if !reflect.DeepEqual(gotXattrs, want) {
+		t.Errorf("xattrs = %v, want %v", gotXattrs, want)
+	}
+}
+
+func TestPAXExtendedHeader(t *testing.T) {
+	recs := []string{formatPAXRecord("path", "foo.txt")}
+	var unpadded int
+	for _, r := range recs {
+		unpadded += len(r)
+	}
+
+	var b Block
+	payload := PAXExtendedHeader(&b, false, recs)
+	if got := b.V7().TypeFlag()[0]; got != TypeXHeader {
+		t.Errorf("Typeflag = %q, want %q", got, TypeXHeader)
+	}
+	var p parser
+	if got := p.parseNumeric(b.V7().Size()); got != int64(unpadded) {
+		t.Errorf("Size = %d, want %d (unpadded, excluding block padding)", got, unpadded)
+	}
+	if len(payload)%blockSize != 0 {
+		t.Errorf("len(payload) = %d, not a multiple of %d", len(payload), blockSize)
+	}
+	if !reflect.DeepEqual(payload, PAXHeaderPayload(recs)) {
+		t.Errorf("payload = %v, want PAXHeaderPayload(recs)", payload)
+	}
+
+	if payload2 := PAXExtendedHeader(&b, true, recs); !reflect.DeepEqual(payload2, payload) {
+		t.Errorf("payload = %v, want %v", payload2, payload)
+	}
+	if got := b.V7().TypeFlag()[0]; got != TypeXGlobalHeader {
+		t.Errorf("Typeflag = %q, want %q", got, TypeXGlobalHeader)
+	}
+}
+
+func TestGlobalRecordsDeterministic(t *testing.T) {
+	fields := map[string]string{"comment": "hi", "charset": "UTF-8"}
+	first := GlobalRecords(fields)
+	for i := 0; i < 10; i++ {
+		if got := GlobalRecords(fields); !reflect.DeepEqual(got, first) {
+			t.Fatalf("GlobalRecords is non-deterministic: got %v, want %v", got, first)
+		}
+	}
+}
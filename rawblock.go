@@ -0,0 +1,213 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// RawEntryKind identifies the role a captured block played in a tar stream.
+type RawEntryKind int
+
+const (
+	// RawHeader is an ordinary entry header block.
+	RawHeader RawEntryKind = iota
+
+	// RawPAXHeader is a PAX extended or global header block, or one of the
+	// payload blocks carrying its key/value records.
+	RawPAXHeader
+
+	// RawLongName is a GNU long-name header block, or one of the payload
+	// blocks carrying the overridden name.
+	RawLongName
+
+	// RawLongLink is a GNU long-link header block, or one of the payload
+	// blocks carrying the overridden link target.
+	RawLongLink
+
+	// RawSparseExtension is a GNU old-style sparse extension block,
+	// chained off a TypeGNUSparse header via IsExtended.
+	RawSparseExtension
+
+	// RawTrailer is one of the two all-zero blocks that terminate an
+	// archive.
+	RawTrailer
+)
+
+func (k RawEntryKind) String() string {
+	switch k {
+	case RawHeader:
+		return "Header"
+	case RawPAXHeader:
+		return "PAXHeader"
+	case RawLongName:
+		return "LongName"
+	case RawLongLink:
+		return "LongLink"
+	case RawSparseExtension:
+		return "SparseExtension"
+	case RawTrailer:
+		return "Trailer"
+	default:
+		return "Unknown"
+	}
+}
+
+// RawEntry is one verbatim block captured from a tar stream by Capture, in
+// the order it appeared. Block is recorded byte-for-byte, preserving any
+// quirks (non-canonical octal encodings, vendor typeflags, etc.) a
+// normalizing reader would otherwise discard.
+//
+// DataSize and Padding are only meaningful on the last RawEntry of an
+// entry's metadata (its header, or the last chained sparse extension block
+// if any): DataSize is the number of bytes that follow, verbatim, from the
+// data stream returned alongside entries by Capture, and Padding is the
+// number of zero bytes that followed those data bytes to reach a 512-byte
+// boundary. Both are zero for entries with no associated payload.
+type RawEntry struct {
+	Kind     RawEntryKind
+	Block    Block
+	DataSize int64
+	Padding  int64
+}
+
+// Capture reads a tar stream block by block and records every header, PAX
+// extended/global header, GNU long-name/long-link, sparse extension, and
+// trailer block verbatim, in the order they appear, as an ordered list of
+// RawEntry. It does not re-encode or normalize anything it reads, so
+// callers that also want the parsed semantics of a block can use the
+// existing HeaderV7/HeaderUSTAR/HeaderGNU/HeaderSTAR views over Block.
+//
+// The second return value is a reader over the concatenation of every
+// entry's file data payload, in stream order, with block padding removed;
+// pass it to Replay along with entries to reconstruct a byte-identical
+// archive.
+//
+// Only the length of each run of block padding is recorded, not its bytes,
+// so Replay writes zeros in its place. Capture also stops reading after the
+// second consecutive zero block; anything that follows it in r (such as
+// further zero blocks filling out a record) is not captured. The replayed
+// stream is byte-identical to r only when all padding is zero and r ends
+// right after those two blocks.
+func Capture(r io.Reader) ([]RawEntry, io.Reader, error) {
+	var entries []RawEntry
+	var data bytes.Buffer
+	var zeroRun int
+
+	for {
+		var blk Block
+		if _, err := io.ReadFull(r, blk[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return entries, &data, err
+		}
+
+		if blk == zeroBlock {
+			entries = append(entries, RawEntry{Kind: RawTrailer, Block: blk})
+			zeroRun++
+			if zeroRun >= 2 {
+				break
+			}
+			continue
+		}
+		zeroRun = 0
+
+		typeFlag := blk.V7().TypeFlag()[0]
+		kind := RawHeader
+		switch typeFlag {
+		case TypeXHeader, TypeXGlobalHeader:
+			kind = RawPAXHeader
+		case TypeGNULongName:
+			kind = RawLongName
+		case TypeGNULongLink:
+			kind = RawLongLink
+		}
+
+		var p parser
+		// Size is commonly GNU base-256 (binary) encoded, not just octal,
+		// for values too large to fit in the field; parseNumeric handles
+		// both so Capture doesn't desync on such entries.
+		size := p.parseNumeric(blk.V7().Size())
+		if p.err != nil {
+			return entries, &data, fmt.Errorf("rawtar: invalid size field: %w", p.err)
+		}
+
+		entries = append(entries, RawEntry{Kind: kind, Block: blk})
+		headerIdx := len(entries) - 1
+
+		// PAX and GNU long-name/long-link headers are themselves followed
+		// by their text payload as further raw blocks (metadata, not file
+		// data), then immediately by the real entry's header.
+		if kind == RawPAXHeader || kind == RawLongName || kind == RawLongLink {
+			nblocks := (size + blockSize - 1) / blockSize
+			for i := int64(0); i < nblocks; i++ {
+				var pblk Block
+				if _, err := io.ReadFull(r, pblk[:]); err != nil {
+					return entries, &data, err
+				}
+				entries = append(entries, RawEntry{Kind: kind, Block: pblk})
+			}
+			continue
+		}
+
+		// A GNU old-style sparse header chains extension blocks for any
+		// fragments beyond the 4 that fit in the header itself.
+		if typeFlag == TypeGNUSparse {
+			sp := blk.GNU().Sparse()
+			for sp.IsExtended()[0] != 0 {
+				var ext Block
+				if _, err := io.ReadFull(r, ext[:]); err != nil {
+					return entries, &data, err
+				}
+				entries = append(entries, RawEntry{Kind: RawSparseExtension, Block: ext})
+				headerIdx = len(entries) - 1
+				sp = ext.Sparse()
+			}
+		}
+
+		if size > 0 {
+			if _, err := io.CopyN(&data, r, size); err != nil {
+				return entries, &data, err
+			}
+		}
+		padding := blockPadding(size)
+		if padding > 0 {
+			if _, err := io.CopyN(io.Discard, r, padding); err != nil {
+				return entries, &data, err
+			}
+		}
+		entries[headerIdx].DataSize = size
+		entries[headerIdx].Padding = padding
+	}
+
+	return entries, &data, nil
+}
+
+// Replay writes entries and data back out to w, reconstructing the
+// original stream byte-for-byte: each Block is written verbatim, and
+// whenever an entry carries a non-zero DataSize or Padding, the
+// corresponding number of bytes are copied from data (or written as zero
+// padding) immediately afterward.
+func Replay(entries []RawEntry, data io.Reader, w io.Writer) error {
+	for _, e := range entries {
+		if _, err := w.Write(e.Block[:]); err != nil {
+			return err
+		}
+		if e.DataSize > 0 {
+			if _, err := io.CopyN(w, data, e.DataSize); err != nil {
+				return err
+			}
+		}
+		if e.Padding > 0 {
+			if _, err := w.Write(make([]byte, e.Padding)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
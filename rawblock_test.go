@@ -0,0 +1,85 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// buildEntry returns a single regular-file header block (with checksum and
+// format set) followed by its padded data, for use as fixture input to
+// Capture.
+func buildEntry(t *testing.T, name string, data []byte, binarySize bool) []byte {
+	t.Helper()
+	var b Block
+	b.SetFormat(FormatGNU)
+	copy(b.V7().Name(), name)
+	b.V7().TypeFlag()[0] = '0'
+	var f formatter
+	if binarySize {
+		formatBinary(b.V7().Size(), int64(len(data)))
+	} else {
+		f.formatNumeric(b.V7().Size(), int64(len(data)))
+	}
+	b.SetFormat(FormatGNU) // Recompute the checksum after editing fields.
+
+	var buf bytes.Buffer
+	buf.Write(b[:])
+	buf.Write(data)
+	if n := blockPadding(int64(len(data))); n > 0 {
+		buf.Write(make([]byte, n))
+	}
+	return buf.Bytes()
+}
+
+func TestCaptureReplayRoundTrip(t *testing.T) {
+	for _, binarySize := range []bool{false, true} {
+		var stream bytes.Buffer
+		stream.Write(buildEntry(t, "foo.txt", []byte("hello, sparse world"), binarySize))
+		stream.Write(zeroBlock[:])
+		stream.Write(zeroBlock[:])
+
+		orig := stream.Bytes()
+		entries, data, err := Capture(bytes.NewReader(orig))
+		if err != nil {
+			t.Fatalf("binarySize=%v: Capture: %v", binarySize, err)
+		}
+
+		var out bytes.Buffer
+		if err := Replay(entries, data, &out); err != nil {
+			t.Fatalf("binarySize=%v: Replay: %v", binarySize, err)
+		}
+		if !bytes.Equal(out.Bytes(), orig) {
+			t.Errorf("binarySize=%v: Replay output does not match original input", binarySize)
+		}
+	}
+}
+
+func TestCaptureBinarySize(t *testing.T) {
+	data := []byte("hello, sparse world")
+	stream := buildEntry(t, "foo.txt", data, true)
+	stream = append(stream, zeroBlock[:]...)
+	stream = append(stream, zeroBlock[:]...)
+
+	entries, ds, err := Capture(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("no entries captured")
+	}
+	if got, want := entries[0].DataSize, int64(len(data)); got != want {
+		t.Errorf("DataSize = %d, want %d", got, want)
+	}
+	got, err := io.ReadAll(ds)
+	if err != nil {
+		t.Fatalf("reading data stream: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("data stream = %q, want %q", got, data)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SparseEntry describes one fragment of actual data within a sparse file,
+// starting at Offset and spanning Length bytes. Everything not covered by
+// a SparseEntry is a hole: a run of zero bytes that the archive does not
+// store explicitly.
+type SparseEntry struct {
+	Offset int64
+	Length int64
+}
+
+// SparseEntries is an ordered, non-overlapping list of SparseEntry values,
+// sorted by Offset, describing the non-hole regions of a sparse file.
+type SparseEntries []SparseEntry
+
+// PhysicalSize reports the number of actual data bytes described by se,
+// i.e., the number of bytes that must be written to the archive for this
+// entry before any block padding is applied.
+func (se SparseEntries) PhysicalSize() int64 {
+	var n int64
+	for _, s := range se {
+		n += s.Length
+	}
+	return n
+}
+
+// PAXSparseVersion identifies a revision of the GNU.sparse.* PAX extension.
+// FormatPAX alone does not distinguish between them, so callers writing a
+// PAX sparse entry must pick one explicitly.
+type PAXSparseVersion int
+
+const (
+	// PAXSparseVersion00 describes a sparse file using one pair of
+	// GNU.sparse.offset/GNU.sparse.numbytes records per fragment.
+	PAXSparseVersion00 PAXSparseVersion = iota
+
+	// PAXSparseVersion01 describes a sparse file using a single
+	// GNU.sparse.map record holding comma-separated offset,numbytes pairs.
+	PAXSparseVersion01
+
+	// PAXSparseVersion10 describes a sparse file using GNU.sparse.major=1,
+	// GNU.sparse.minor=0, GNU.sparse.name, and GNU.sparse.realsize records,
+	// plus an in-band sparse header at the start of the file's data
+	// payload; see GNUSparseDataHeader.
+	PAXSparseVersion10
+)
+
+// GNUSparseHeader writes the GNU old-style sparse header into b: it sets
+// Typeflag to TypeGNUSparse, fills in the in-header sparse map (up to 4
+// entries), sets Size to se's physical stored size and RealSize to
+// realSize, and chains any entries beyond the first 4 into extension
+// blocks via IsExtended. It returns the extension blocks that must be
+// written to the archive immediately following b, in order; len(ext) is
+// zero if se has at most 4 entries.
+func GNUSparseHeader(b *Block, se SparseEntries, realSize int64) (ext []Block) {
+	b.V7().TypeFlag()[0] = TypeGNUSparse
+
+	var f formatter
+	f.formatNumeric(b.V7().Size(), se.PhysicalSize())
+	sp := b.GNU().Sparse()
+	head, rest := se, SparseEntries(nil)
+	if n := sp.MaxEntries(); len(head) > n {
+		head, rest = se[:n], se[n:]
+	}
+	for i, s := range head {
+		ent := sp.Entry(i)
+		f.formatNumeric(ent.Offset(), s.Offset)
+		f.formatNumeric(ent.Length(), s.Length)
+	}
+	if len(rest) > 0 {
+		sp.IsExtended()[0] = 1
+		ext = gnuSparseExtensions(rest)
+	}
+	f.formatNumeric(b.GNU().RealSize(), realSize)
+	return ext
+}
+
+// gnuSparseExtensions encodes se into as many GNU sparse extension blocks
+// as needed, 21 entries per block, chaining each block to the next via
+// IsExtended until se is exhausted.
+func gnuSparseExtensions(se SparseEntries) []Block {
+	var blocks []Block
+	for len(se) > 0 {
+		var blk Block
+		var f formatter
+		sp := blk.Sparse()
+		head, rest := se, SparseEntries(nil)
+		if n := sp.MaxEntries(); len(head) > n {
+			head, rest = se[:n], se[n:]
+		}
+		for i, s := range head {
+			ent := sp.Entry(i)
+			f.formatNumeric(ent.Offset(), s.Offset)
+			f.formatNumeric(ent.Length(), s.Length)
+		}
+		if len(rest) > 0 {
+			sp.IsExtended()[0] = 1
+		}
+		blocks = append(blocks, blk)
+		se = rest
+	}
+	return blocks
+}
+
+// GNUSparseDataHeader renders the version 1.0 in-band sparse header that
+// must be written as the start of a PAXSparseVersion10 entry's data
+// payload: the decimal fragment count on its own line, followed by one
+// "offset\nnumbytes\n" line per fragment, NUL-padded out to a 512-byte
+// boundary.
+func GNUSparseDataHeader(se SparseEntries) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d\n", len(se))
+	for _, s := range se {
+		fmt.Fprintf(&buf, "%d\n%d\n", s.Offset, s.Length)
+	}
+	if n := blockPadding(int64(buf.Len())); n > 0 {
+		buf.Write(make([]byte, n))
+	}
+	return buf.Bytes()
+}
+
+// Version10PhysicalSize reports the Size field value for a PAXSparseVersion10
+// entry: the length of its in-band sparse header (see GNUSparseDataHeader)
+// plus se.PhysicalSize(), before block padding.
+func (se SparseEntries) Version10PhysicalSize() int64 {
+	return int64(len(GNUSparseDataHeader(se))) + se.PhysicalSize()
+}
+
+// GNUSparsePAXRecords returns the PAX records needed to describe se as a
+// GNU.sparse.* sparse file under the given version, for inclusion in a PAX
+// extended header's payload (see PAXHeaderPayload). name is only used (and
+// required) for PAXSparseVersion10, where it becomes the GNU.sparse.name
+// record holding the file's real name.
+//
+// For PAXSparseVersion10, the caller must additionally prepend
+// GNUSparseDataHeader(se) to the entry's data payload; the other two
+// versions carry no in-band header.
+func GNUSparsePAXRecords(se SparseEntries, realSize int64, name string, ver PAXSparseVersion) []string {
+	switch ver {
+	case PAXSparseVersion00:
+		recs := []string{formatPAXRecord("GNU.sparse.size", strconv.FormatInt(realSize, 10))}
+		for _, s := range se {
+			recs = append(recs,
+				formatPAXRecord("GNU.sparse.offset", strconv.FormatInt(s.Offset, 10)),
+				formatPAXRecord("GNU.sparse.numbytes", strconv.FormatInt(s.Length, 10)))
+		}
+		return recs
+	case PAXSparseVersion01:
+		return []string{
+			formatPAXRecord("GNU.sparse.size", strconv.FormatInt(realSize, 10)),
+			formatPAXRecord("GNU.sparse.map", gnuSparseMap01(se)),
+		}
+	case PAXSparseVersion10:
+		return []string{
+			formatPAXRecord("GNU.sparse.major", "1"),
+			formatPAXRecord("GNU.sparse.minor", "0"),
+			formatPAXRecord("GNU.sparse.name", name),
+			formatPAXRecord("GNU.sparse.realsize", strconv.FormatInt(realSize, 10)),
+		}
+	default:
+		panic("rawtar: invalid PAXSparseVersion")
+	}
+}
+
+// gnuSparseMap01 renders se as the comma-separated "offset,numbytes,..."
+// value of a GNU.sparse.map PAX record.
+func gnuSparseMap01(se SparseEntries) string {
+	parts := make([]string, 0, 2*len(se))
+	for _, s := range se {
+		parts = append(parts, strconv.FormatInt(s.Offset, 10), strconv.FormatInt(s.Length, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatPAXRecord encodes a single PAX extended header record as
+// "LENGTH KEY=VALUE\n". LENGTH is the record's own total length in bytes,
+// including the length field itself; since the digit count of LENGTH
+// depends on its own value, it is resolved by successive approximation
+// until a fixed point is reached.
+func formatPAXRecord(key, value string) string {
+	const padding = 3 // ' ', '=', and '\n'
+	base := len(key) + len(value) + padding
+	size := base
+	for {
+		n := len(strconv.Itoa(size)) + base
+		if n == size {
+			break
+		}
+		size = n
+	}
+	return strconv.Itoa(size) + " " + key + "=" + value + "\n"
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGNUSparseHeaderSize(t *testing.T) {
+	se := SparseEntries{{Offset: 0, Length: 100}, {Offset: 1000, Length: 50}}
+	var b Block
+	ext := GNUSparseHeader(&b, se, 2000)
+	if len(ext) != 0 {
+		t.Fatalf("len(ext) = %d, want 0 for 2 entries", len(ext))
+	}
+
+	var p parser
+	if got, want := p.parseNumeric(b.V7().Size()), se.PhysicalSize(); got != want {
+		t.Errorf("Size = %d, want %d (PhysicalSize)", got, want)
+	}
+	if got, want := p.parseNumeric(b.GNU().RealSize()), int64(2000); got != want {
+		t.Errorf("RealSize = %d, want %d", got, want)
+	}
+	if got := b.V7().TypeFlag()[0]; got != TypeGNUSparse {
+		t.Errorf("TypeFlag = %q, want %q", got, TypeGNUSparse)
+	}
+	if p.err != nil {
+		t.Fatalf("parse error: %v", p.err)
+	}
+}
+
+func TestGNUSparseHeaderExtension(t *testing.T) {
+	// 4 entries fit in the header; a 5th must force exactly one extension
+	// block, and a 21st entry within that block must not force a second.
+	mk := func(n int) SparseEntries {
+		se := make(SparseEntries, n)
+		for i := range se {
+			se[i] = SparseEntry{Offset: int64(i * 1000), Length: 10}
+		}
+		return se
+	}
+
+	tests := []struct {
+		n        int
+		wantExt  int
+		extended bool
+	}{
+		{4, 0, false},
+		{5, 1, true},
+		{25, 1, true},
+		{26, 2, true},
+	}
+	for _, tt := range tests {
+		var b Block
+		ext := GNUSparseHeader(&b, mk(tt.n), 0)
+		if len(ext) != tt.wantExt {
+			t.Errorf("n=%d: len(ext) = %d, want %d", tt.n, len(ext), tt.wantExt)
+		}
+		if got := b.GNU().Sparse().IsExtended()[0] != 0; got != tt.extended {
+			t.Errorf("n=%d: IsExtended = %v, want %v", tt.n, got, tt.extended)
+		}
+	}
+}
+
+func TestGNUSparseDataHeaderPadding(t *testing.T) {
+	se := SparseEntries{{Offset: 0, Length: 5}}
+	h := GNUSparseDataHeader(se)
+	if len(h)%blockSize != 0 {
+		t.Fatalf("len(h) = %d, not a multiple of %d", len(h), blockSize)
+	}
+	want := "1\n0\n5\n"
+	if !strings.HasPrefix(string(h), want) {
+		t.Errorf("header = %q, want prefix %q", h, want)
+	}
+}
+
+func TestGNUSparsePAXRecordsVersions(t *testing.T) {
+	se := SparseEntries{{Offset: 0, Length: 5}, {Offset: 10, Length: 5}}
+
+	recs := GNUSparsePAXRecords(se, 20, "", PAXSparseVersion00)
+	joined := strings.Join(recs, "")
+	for _, want := range []string{"GNU.sparse.size=20", "GNU.sparse.offset=0", "GNU.sparse.numbytes=5", "GNU.sparse.offset=10"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("v0.0 records = %q, missing %q", joined, want)
+		}
+	}
+
+	recs = GNUSparsePAXRecords(se, 20, "", PAXSparseVersion01)
+	joined = strings.Join(recs, "")
+	if !strings.Contains(joined, "GNU.sparse.map=0,5,10,5") {
+		t.Errorf("v0.1 records = %q, missing expected GNU.sparse.map", joined)
+	}
+
+	recs = GNUSparsePAXRecords(se, 20, "myfile", PAXSparseVersion10)
+	joined = strings.Join(recs, "")
+	for _, want := range []string{"GNU.sparse.major=1", "GNU.sparse.minor=0", "GNU.sparse.name=myfile", "GNU.sparse.realsize=20"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("v1.0 records = %q, missing %q", joined, want)
+		}
+	}
+}
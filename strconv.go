@@ -0,0 +1,132 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawtar
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// parser decodes the numeric and string fields of a tar header block,
+// recording the first error it encounters so callers can check it once
+// after a sequence of parses instead of after every call.
+type parser struct {
+	err error
+}
+
+// parseOctal parses b as an ASCII octal integer, ignoring leading/trailing
+// spaces and NUL bytes. An empty field parses as zero.
+func (p *parser) parseOctal(b []byte) int64 {
+	b = bytes.Trim(b, " \x00")
+	if len(b) == 0 {
+		return 0
+	}
+	x, err := strconv.ParseInt(string(b), 8, 64)
+	if err != nil {
+		p.err = err
+		return 0
+	}
+	return x
+}
+
+// parseNumeric parses b as either an ASCII octal integer, or, if the high
+// bit of the first byte is set, a GNU base-256 big-endian binary integer.
+// Base-256 encoding is how GNU (and some PAX) archives represent values
+// too large to fit the field in octal, such as a uint89 Size.
+func (p *parser) parseNumeric(b []byte) int64 {
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		return parseBinary(b)
+	}
+	return p.parseOctal(b)
+}
+
+// parseBinary decodes b as a GNU base-256 binary integer: the high bit of
+// b[0] marks the encoding, the next bit is the sign, and the remaining
+// bits (across all of b) hold a big-endian magnitude.
+func parseBinary(b []byte) int64 {
+	sign := b[0]&0x40 != 0
+	var x uint64
+	x = uint64(b[0] & 0x3f)
+	for _, c := range b[1:] {
+		x = x<<8 | uint64(c)
+	}
+	if sign {
+		return -int64(x)
+	}
+	return int64(x)
+}
+
+// formatter encodes numeric fields of a tar header block, recording the
+// first error it encounters (e.g. a value too large for its field) so
+// callers can check it once after a sequence of formats.
+type formatter struct {
+	err error
+}
+
+// formatOctal writes x into b as a zero-padded ASCII octal integer,
+// terminated by a single NUL at b[len(b)-1]. x must fit in the available
+// digits; if it doesn't, f.err is set and formatNumeric should be used
+// instead.
+func (f *formatter) formatOctal(b []byte, x int64) {
+	if x < 0 || x >= fitsInOctal(len(b)) {
+		f.err = errFieldTooLong
+		x = 0
+	}
+	s := strconv.FormatInt(x, 8)
+	if pad := len(b) - 1 - len(s); pad > 0 {
+		s = zeros[:pad] + s
+	}
+	copy(b, s)
+	b[len(b)-1] = 0
+}
+
+// formatNumeric writes x into b as an ASCII octal integer if it fits, or
+// otherwise as a GNU base-256 big-endian binary integer. This is the
+// encoding needed for fields such as GNU's uint89 Size, which routinely
+// exceed what octal can represent in a fixed-width field.
+func (f *formatter) formatNumeric(b []byte, x int64) {
+	if x >= 0 && x < fitsInOctal(len(b)) {
+		f.formatOctal(b, x)
+		return
+	}
+	formatBinary(b, x)
+}
+
+// formatBinary writes x into b as a GNU base-256 binary integer: the high
+// bit of b[0] marks the encoding, the next bit carries the sign, and the
+// remaining bits (across all of b) hold the big-endian magnitude.
+func formatBinary(b []byte, x int64) {
+	neg := x < 0
+	if neg {
+		x = -x
+	}
+	for i := len(b) - 1; i > 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+	b[0] = 0x80
+	if neg {
+		b[0] |= 0x40
+	}
+}
+
+// fitsInOctal reports the exclusive upper bound of values representable as
+// an octal integer in a field of the given width, which reserves its last
+// byte for the NUL terminator.
+func fitsInOctal(width int) int64 {
+	return int64(1) << uint(3*(width-1))
+}
+
+// zeros is a source of '0' bytes for padding formatOctal's output; it is
+// longer than any field this package formats.
+const zeros = "000000000000000000000000"
+
+// errFieldTooLong reports that a numeric value does not fit the octal
+// encoding of its field and must be formatted as base-256 binary instead.
+var errFieldTooLong = errFieldTooLongError{}
+
+type errFieldTooLongError struct{}
+
+func (errFieldTooLongError) Error() string { return "rawtar: field too long for octal encoding" }